@@ -1,28 +1,27 @@
 package background
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"fmt"
-	"net/url"
-
-	"github.com/sourcegraph/sourcegraph/internal/api"
-
-	"golang.org/x/net/context/ctxhttp"
 
 	"github.com/pkg/errors"
+
+	"github.com/sourcegraph/sourcegraph/internal/api"
+	"github.com/sourcegraph/sourcegraph/internal/gqlclient"
 )
 
-type graphQLQuery struct {
-	Query     string      `json:"query"`
-	Variables interface{} `json:"variables"`
-}
+var internalGQLClient = gqlclient.New(api.InternalClient.URL)
+
+// internalGQLBatcher coalesces concurrent first-page Search queries -
+// the common case when, e.g., the saved-search watcher ticks many
+// watches at once - into a single HTTP round-trip.
+var internalGQLBatcher = &gqlclient.Batcher{Client: internalGQLClient}
 
 const gqlSearchQuery = `query Search(
 	$query: String!,
+	$first: Int,
+	$after: String,
 ) {
-	search(query: $query, ) {
+	search(query: $query, first: $first, after: $after) {
 		results {
 			limitHit
 			cloning { name }
@@ -33,70 +32,93 @@ const gqlSearchQuery = `query Search(
 				title
 				description
 			}
+			pageInfo {
+				hasNextPage
+				endCursor
+			}
+			results {
+				__typename
+				... on FileMatch {
+					repository { name }
+					file { path }
+				}
+			}
 		}
 	}
 }`
 
 type gqlSearchVars struct {
-	Query string `json:"query"`
+	Query string  `json:"query"`
+	First *int    `json:"first,omitempty"`
+	After *string `json:"after,omitempty"`
 }
 
-type gqlSearchResponse struct {
-	Data struct {
-		Search struct {
-			Results struct {
-				LimitHit   bool
-				Cloning    []*api.Repo
-				Missing    []*api.Repo
-				Timedout   []*api.Repo
-				MatchCount int
-				Alert      struct {
-					Title       string
-					Description string
-				}
-			}
-		}
+type gqlSearchResults struct {
+	LimitHit   bool
+	Cloning    []*api.Repo
+	Missing    []*api.Repo
+	Timedout   []*api.Repo
+	MatchCount int
+	Alert      struct {
+		Title       string
+		Description string
+	}
+	PageInfo struct {
+		HasNextPage bool
+		EndCursor   string
 	}
-	Errors []interface{}
+	Results []gqlFileMatch
 }
 
-func search(ctx context.Context, query string) (*gqlSearchResponse, error) {
-	var buf bytes.Buffer
-	err := json.NewEncoder(&buf).Encode(graphQLQuery{
-		Query:     gqlSearchQuery,
-		Variables: gqlSearchVars{Query: query},
-	})
-	if err != nil {
-		return nil, errors.Wrap(err, "Encode")
+// gqlFileMatch is a single result of a FileMatch-typed search result.
+type gqlFileMatch struct {
+	Typename   string `json:"__typename"`
+	Repository struct {
+		Name string
 	}
-
-	url, err := gqlURL("Search")
-	if err != nil {
-		return nil, errors.Wrap(err, "constructing frontend URL")
+	File struct {
+		Path string
 	}
+}
 
-	resp, err := ctxhttp.Post(ctx, nil, url, "application/json", &buf)
-	if err != nil {
-		return nil, errors.Wrap(err, "Post")
-	}
-	defer resp.Body.Close()
+// ID returns a stable identifier for this match, suitable for diffing
+// across runs (e.g. by the saved-search watcher).
+func (m gqlFileMatch) ID() string {
+	return m.Repository.Name + "#" + m.File.Path
+}
 
-	var res *gqlSearchResponse
-	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
-		return nil, errors.Wrap(err, "Decode")
+type gqlSearchData struct {
+	Search struct {
+		Results gqlSearchResults
 	}
-	if len(res.Errors) > 0 {
-		return res, fmt.Errorf("graphql: errors: %v", res.Errors)
-	}
-	return res, nil
 }
 
-func gqlURL(queryName string) (string, error) {
-	u, err := url.Parse(api.InternalClient.URL)
+// search runs query and returns its first page of results. Callers that
+// need to scan more than one page should use SearchStream instead.
+func search(ctx context.Context, query string) (*gqlSearchData, error) {
+	return doSearch(ctx, query, nil, nil)
+}
+
+func doSearch(ctx context.Context, query string, first *int, after *string) (*gqlSearchData, error) {
+	var data gqlSearchData
+	req := gqlclient.Request{
+		Query:     gqlSearchQuery,
+		Variables: gqlSearchVars{Query: query, First: first, After: after},
+	}
+
+	var err error
+	if after == nil {
+		// First pages are the common case for concurrent callers, so
+		// route them through the shared Batcher to coalesce concurrent
+		// HTTP round-trips. Later pages of a single stream are rare and
+		// already serialized by the caller, so they go straight through
+		// the Client.
+		err = internalGQLBatcher.Query(ctx, "Search", req, &data).Wait(ctx)
+	} else {
+		err = internalGQLClient.Query(ctx, "Search", req, &data)
+	}
 	if err != nil {
-		return "", err
+		return nil, errors.Wrap(err, "query Search")
 	}
-	u.Path = "/.internal/graphql"
-	u.RawQuery = queryName
-	return u.String(), nil
+	return &data, nil
 }
@@ -0,0 +1,123 @@
+package background
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// ErrSearchIncomplete is set as SearchEvent.Err on the final event when a
+// page reports a degraded search: LimitHit, or a non-empty
+// Cloning/Missing/Timedout. The page's Results are still populated on
+// that same event, so callers can process the partial data while
+// treating the search as incomplete.
+var ErrSearchIncomplete = errors.New("search: incomplete results (limit hit, or cloning/missing/timedout repos)")
+
+// searchPager is the paginated search call used by SearchStream. It's a
+// package var rather than a parameter so tests can substitute a fake
+// without threading a new parameter through every caller, the same
+// seam watches.Watcher.SearchStream uses for the stream itself.
+var searchPager = doSearch
+
+// SearchStreamOptions configures SearchStream.
+type SearchStreamOptions struct {
+	// PageSize is the number of results requested per page (the GraphQL
+	// "first" argument). Zero lets the server choose a default.
+	PageSize int
+
+	// Cursor resumes a previous stream from a SearchEvent.Cursor value,
+	// instead of starting from the first page.
+	Cursor string
+}
+
+// SearchEvent is a single event emitted on the channel returned by
+// SearchStream.
+type SearchEvent struct {
+	// Results is this page's data. Zero value when Err is set to
+	// anything other than ErrSearchIncomplete.
+	Results gqlSearchResults
+
+	// Cursor can be passed as SearchStreamOptions.Cursor to resume
+	// paging after this event.
+	Cursor string
+
+	// Err is set on the final event if the stream ended due to a query
+	// error (including one caused by ctx cancellation) or
+	// ErrSearchIncomplete. If ctx is cancelled while an event is waiting
+	// to be received, that event is dropped and the channel is closed
+	// without an error event.
+	Err error
+}
+
+// SearchStream runs query, transparently paging through results with
+// first/after cursor arguments until hasNextPage is false or ctx is
+// cancelled, and sends each page as a SearchEvent on the returned
+// channel. The channel is closed when the stream ends.
+//
+// A page reporting LimitHit, or a non-empty Cloning/Missing/Timedout, is
+// treated as terminal: it is sent with Err set to ErrSearchIncomplete,
+// and the stream ends without requesting further pages even if
+// hasNextPage was true. Callers that need to tell real matches apart
+// from this degraded-search signal should check the sentinel with
+// errors.Is(ev.Err, ErrSearchIncomplete) rather than re-deriving it from
+// the result fields themselves.
+func SearchStream(ctx context.Context, query string, opts SearchStreamOptions) (<-chan SearchEvent, error) {
+	events := make(chan SearchEvent)
+
+	go func() {
+		defer close(events)
+
+		after := opts.Cursor
+		for {
+			var afterArg *string
+			if after != "" {
+				afterArg = &after
+			}
+			var firstArg *int
+			if opts.PageSize > 0 {
+				firstArg = &opts.PageSize
+			}
+
+			data, err := searchPager(ctx, query, firstArg, afterArg)
+			if err != nil {
+				sendEvent(ctx, events, SearchEvent{Err: err})
+				return
+			}
+
+			results := data.Search.Results
+			ev := SearchEvent{Results: results, Cursor: results.PageInfo.EndCursor}
+			incomplete := isIncompleteResults(results)
+			if incomplete {
+				ev.Err = ErrSearchIncomplete
+			}
+
+			if !sendEvent(ctx, events, ev) {
+				return
+			}
+			if incomplete || !results.PageInfo.HasNextPage {
+				return
+			}
+			after = results.PageInfo.EndCursor
+		}
+	}()
+
+	return events, nil
+}
+
+// isIncompleteResults reports whether a page of results indicates a
+// degraded search: the result limit was hit, or some repositories were
+// cloning, missing, or timed out.
+func isIncompleteResults(r gqlSearchResults) bool {
+	return r.LimitHit || len(r.Cloning) > 0 || len(r.Missing) > 0 || len(r.Timedout) > 0
+}
+
+// sendEvent delivers ev on events, returning false without blocking
+// forever if ctx is cancelled first.
+func sendEvent(ctx context.Context, events chan<- SearchEvent, ev SearchEvent) bool {
+	select {
+	case events <- ev:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,185 @@
+package background
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/internal/api"
+)
+
+func TestIsIncompleteResults(t *testing.T) {
+	tests := []struct {
+		name string
+		r    gqlSearchResults
+		want bool
+	}{
+		{name: "empty", r: gqlSearchResults{}, want: false},
+		{name: "limit hit", r: gqlSearchResults{LimitHit: true}, want: true},
+		{name: "cloning", r: gqlSearchResults{Cloning: []*api.Repo{{}}}, want: true},
+		{name: "missing", r: gqlSearchResults{Missing: []*api.Repo{{}}}, want: true},
+		{name: "timedout", r: gqlSearchResults{Timedout: []*api.Repo{{}}}, want: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isIncompleteResults(tc.r); got != tc.want {
+				t.Errorf("isIncompleteResults(%+v) = %v, want %v", tc.r, got, tc.want)
+			}
+		})
+	}
+}
+
+// TestSearchStream_Pagination drives a fake searchPager across two pages
+// and asserts the channel closes once hasNextPage is false, without
+// requesting a third page.
+func TestSearchStream_Pagination(t *testing.T) {
+	orig := searchPager
+	defer func() { searchPager = orig }()
+
+	var afters []string
+	searchPager = func(ctx context.Context, query string, first *int, after *string) (*gqlSearchData, error) {
+		got := ""
+		if after != nil {
+			got = *after
+		}
+		afters = append(afters, got)
+
+		var data gqlSearchData
+		switch len(afters) {
+		case 1:
+			data.Search.Results.PageInfo.HasNextPage = true
+			data.Search.Results.PageInfo.EndCursor = "page2"
+		case 2:
+			data.Search.Results.PageInfo.HasNextPage = false
+		default:
+			t.Fatalf("unexpected page %d request (after=%q)", len(afters), got)
+		}
+		return &data, nil
+	}
+
+	events, err := SearchStream(context.Background(), "q", SearchStreamOptions{})
+	if err != nil {
+		t.Fatalf("SearchStream: %v", err)
+	}
+
+	var got []SearchEvent
+	for ev := range events {
+		got = append(got, ev)
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("got %d events, want 2", len(got))
+	}
+	if got[0].Cursor != "page2" {
+		t.Errorf("first event cursor = %q, want %q", got[0].Cursor, "page2")
+	}
+	if len(afters) != 2 || afters[0] != "" || afters[1] != "page2" {
+		t.Errorf("unexpected pagination requests: %v", afters)
+	}
+}
+
+// TestSearchStream_StopsOnIncompleteResults asserts that a page reporting
+// a degraded search ends the stream, even though hasNextPage is true,
+// and that no further page is requested.
+func TestSearchStream_StopsOnIncompleteResults(t *testing.T) {
+	orig := searchPager
+	defer func() { searchPager = orig }()
+
+	calls := 0
+	searchPager = func(ctx context.Context, query string, first *int, after *string) (*gqlSearchData, error) {
+		calls++
+		var data gqlSearchData
+		data.Search.Results.LimitHit = true
+		data.Search.Results.PageInfo.HasNextPage = true
+		data.Search.Results.PageInfo.EndCursor = "page2"
+		return &data, nil
+	}
+
+	events, err := SearchStream(context.Background(), "q", SearchStreamOptions{})
+	if err != nil {
+		t.Fatalf("SearchStream: %v", err)
+	}
+
+	var got []SearchEvent
+	for ev := range events {
+		got = append(got, ev)
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("got %d events, want 1", len(got))
+	}
+	if !errors.Is(got[0].Err, ErrSearchIncomplete) {
+		t.Errorf("got[0].Err = %v, want ErrSearchIncomplete", got[0].Err)
+	}
+	if calls != 1 {
+		t.Errorf("searchPager called %d times, want 1 (no further paging after an incomplete page)", calls)
+	}
+}
+
+// TestSearchStream_PropagatesQueryError asserts that a pager error (as a
+// real HTTP failure, including one caused by ctx cancellation, would
+// return) is sent as the terminal event and closes the channel.
+func TestSearchStream_PropagatesQueryError(t *testing.T) {
+	orig := searchPager
+	defer func() { searchPager = orig }()
+
+	wantErr := errors.New("boom")
+	searchPager = func(ctx context.Context, query string, first *int, after *string) (*gqlSearchData, error) {
+		return nil, wantErr
+	}
+
+	events, err := SearchStream(context.Background(), "q", SearchStreamOptions{})
+	if err != nil {
+		t.Fatalf("SearchStream: %v", err)
+	}
+
+	ev, ok := <-events
+	if !ok {
+		t.Fatal("expected one event before the channel closed")
+	}
+	if !errors.Is(ev.Err, wantErr) {
+		t.Errorf("ev.Err = %v, want %v", ev.Err, wantErr)
+	}
+	if _, ok := <-events; ok {
+		t.Fatal("expected the channel to be closed after the error event")
+	}
+}
+
+// TestSearchStream_StopsWhenContextCancelled asserts that the stream
+// stops sending once ctx is cancelled, rather than blocking forever on a
+// consumer that has stopped reading.
+func TestSearchStream_StopsWhenContextCancelled(t *testing.T) {
+	orig := searchPager
+	defer func() { searchPager = orig }()
+
+	searchPager = func(ctx context.Context, query string, first *int, after *string) (*gqlSearchData, error) {
+		var data gqlSearchData
+		data.Search.Results.PageInfo.HasNextPage = true
+		data.Search.Results.PageInfo.EndCursor = "next"
+		return &data, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := SearchStream(ctx, "q", SearchStreamOptions{})
+	if err != nil {
+		t.Fatalf("SearchStream: %v", err)
+	}
+
+	// Abandon the stream without reading from it, and cancel ctx. Give
+	// the producer goroutine a moment to reach its blocked send and
+	// observe the cancellation before we start reading, so the channel
+	// is already closing rather than racing a concurrent receive.
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Fatal("expected no further event once the consumer abandoned the stream and ctx was cancelled")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the channel to close after ctx cancellation")
+	}
+}
@@ -0,0 +1,138 @@
+package watches
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/keegancsmith/sqlf"
+	"github.com/lib/pq"
+
+	"github.com/sourcegraph/sourcegraph/internal/database/basestore"
+)
+
+// dbStore is the Postgres-backed Store. It persists watches, ignores,
+// and run state in the frontend database, in the saved_search_watches,
+// saved_search_watch_ignores, and saved_search_watch_run_state tables.
+type dbStore struct {
+	*basestore.Store
+}
+
+// NewStore returns a Store backed by the frontend database.
+func NewStore(db *sql.DB) Store {
+	return &dbStore{Store: basestore.NewWithHandle(basestore.NewHandleWithDB(db, sql.TxOptions{}))}
+}
+
+func (s *dbStore) ListWatches(ctx context.Context) ([]*Watch, error) {
+	rows, err := s.Query(ctx, sqlf.Sprintf(`
+		SELECT id, user_id, query, created_at
+		FROM saved_search_watches
+		ORDER BY id
+	`))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var watches []*Watch
+	for rows.Next() {
+		var w Watch
+		if err := rows.Scan(&w.ID, &w.UserID, &w.Query, &w.CreatedAt); err != nil {
+			return nil, err
+		}
+		watches = append(watches, &w)
+	}
+	return watches, rows.Err()
+}
+
+func (s *dbStore) AddWatch(ctx context.Context, userID int32, query string) (*Watch, error) {
+	w := &Watch{UserID: userID, Query: query}
+	err := s.QueryRow(ctx, sqlf.Sprintf(`
+		INSERT INTO saved_search_watches (user_id, query)
+		VALUES (%s, %s)
+		RETURNING id, created_at
+	`, userID, query)).Scan(&w.ID, &w.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (s *dbStore) RemoveWatch(ctx context.Context, watchID int64, userID int32) error {
+	return s.Exec(ctx, sqlf.Sprintf(`
+		DELETE FROM saved_search_watches WHERE id = %s AND user_id = %s
+	`, watchID, userID))
+}
+
+func (s *dbStore) ListIgnores(ctx context.Context, watchID int64) ([]*Ignore, error) {
+	rows, err := s.Query(ctx, sqlf.Sprintf(`
+		SELECT watch_id, repo_name, result_id
+		FROM saved_search_watch_ignores
+		WHERE watch_id = %s
+	`, watchID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ignores []*Ignore
+	for rows.Next() {
+		var ig Ignore
+		if err := rows.Scan(&ig.WatchID, &ig.RepoName, &ig.ResultID); err != nil {
+			return nil, err
+		}
+		ignores = append(ignores, &ig)
+	}
+	return ignores, rows.Err()
+}
+
+func (s *dbStore) AddIgnore(ctx context.Context, watchID int64, userID int32, repoName, resultID string) error {
+	return s.Exec(ctx, sqlf.Sprintf(`
+		INSERT INTO saved_search_watch_ignores (watch_id, user_id, repo_name, result_id)
+		VALUES (%s, %s, %s, %s)
+		ON CONFLICT DO NOTHING
+	`, watchID, userID, repoName, resultID))
+}
+
+func (s *dbStore) RemoveIgnore(ctx context.Context, watchID int64, userID int32, repoName, resultID string) error {
+	return s.Exec(ctx, sqlf.Sprintf(`
+		DELETE FROM saved_search_watch_ignores
+		WHERE watch_id = %s AND user_id = %s AND repo_name = %s AND result_id = %s
+	`, watchID, userID, repoName, resultID))
+}
+
+func (s *dbStore) GetRunState(ctx context.Context, watchID int64) (*RunState, error) {
+	state := &RunState{WatchID: watchID}
+
+	var lastRanAt sql.NullTime
+	var seenIDs []string
+	err := s.QueryRow(ctx, sqlf.Sprintf(`
+		SELECT last_ran_at, seen_ids
+		FROM saved_search_watch_run_state
+		WHERE watch_id = %s
+	`, watchID)).Scan(&lastRanAt, pq.Array(&seenIDs))
+	if err == sql.ErrNoRows {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	state.LastRanAt = lastRanAt.Time
+	state.SeenIDs = make(map[string]struct{}, len(seenIDs))
+	for _, id := range seenIDs {
+		state.SeenIDs[id] = struct{}{}
+	}
+	return state, nil
+}
+
+func (s *dbStore) SetRunState(ctx context.Context, state *RunState) error {
+	seenIDs := make([]string, 0, len(state.SeenIDs))
+	for id := range state.SeenIDs {
+		seenIDs = append(seenIDs, id)
+	}
+	return s.Exec(ctx, sqlf.Sprintf(`
+		INSERT INTO saved_search_watch_run_state (watch_id, last_ran_at, seen_ids)
+		VALUES (%s, %s, %s)
+		ON CONFLICT (watch_id) DO UPDATE SET last_ran_at = excluded.last_ran_at, seen_ids = excluded.seen_ids
+	`, state.WatchID, state.LastRanAt, pq.Array(seenIDs)))
+}
@@ -0,0 +1,105 @@
+package watches
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// Notifier delivers a NewMatches event to a user through some channel
+// (email, webhook, Slack, ...).
+type Notifier interface {
+	Notify(ctx context.Context, userID int32, matches NewMatches) error
+}
+
+// MultiNotifier fans a NewMatches event out to every Notifier in order,
+// stopping at the first error.
+type MultiNotifier []Notifier
+
+func (m MultiNotifier) Notify(ctx context.Context, userID int32, matches NewMatches) error {
+	for _, n := range m {
+		if err := n.Notify(ctx, userID, matches); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// postJSON marshals payload and POSTs it to url, returning an error if
+// the request fails or the response status indicates failure.
+func postJSON(ctx context.Context, httpClient *http.Client, url string, payload interface{}) error {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(payload); err != nil {
+		return errors.Wrap(err, "Encode")
+	}
+
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &buf)
+	if err != nil {
+		return errors.Wrap(err, "NewRequest")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "Do")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return errors.Errorf("unexpected status %d posting to %s", resp.StatusCode, url)
+	}
+	return nil
+}
+
+// WebhookNotifier posts a JSON-encoded NewMatches payload to a per-user
+// webhook URL.
+type WebhookNotifier struct {
+	HTTPClient *http.Client
+
+	// URL returns the webhook URL to notify for userID, or "" to skip
+	// notification.
+	URL func(ctx context.Context, userID int32) (string, error)
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, userID int32, matches NewMatches) error {
+	url, err := n.URL(ctx, userID)
+	if err != nil {
+		return errors.Wrap(err, "resolving webhook URL")
+	}
+	if url == "" {
+		return nil
+	}
+	return postJSON(ctx, n.HTTPClient, url, matches)
+}
+
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+// SlackNotifier posts a NewMatches summary to a Slack incoming webhook.
+type SlackNotifier struct {
+	HTTPClient *http.Client
+
+	// WebhookURL returns the Slack incoming-webhook URL to notify for
+	// userID, or "" to skip notification.
+	WebhookURL func(ctx context.Context, userID int32) (string, error)
+}
+
+func (n *SlackNotifier) Notify(ctx context.Context, userID int32, matches NewMatches) error {
+	url, err := n.WebhookURL(ctx, userID)
+	if err != nil {
+		return errors.Wrap(err, "resolving Slack webhook URL")
+	}
+	if url == "" {
+		return nil
+	}
+
+	text := fmt.Sprintf("%s: %d new result(s)", matches.Watch.Query, len(matches.Results))
+	return postJSON(ctx, n.HTTPClient, url, slackMessage{Text: text})
+}
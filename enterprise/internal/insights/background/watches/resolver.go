@@ -0,0 +1,82 @@
+package watches
+
+import (
+	"context"
+
+	"github.com/sourcegraph/sourcegraph/internal/actor"
+)
+
+// Resolver implements the saved-search-watch GraphQL mutations
+// (addSavedSearchWatch, removeSavedSearchWatch,
+// addSavedSearchWatchIgnore, removeSavedSearchWatchIgnore). It is not
+// yet registered against the GraphQL schema or the root mutation
+// resolver - that wiring, alongside the other insights mutations, is
+// still pending.
+type Resolver struct {
+	Store Store
+}
+
+// AddWatchArgs are the arguments to the addSavedSearchWatch mutation.
+type AddWatchArgs struct {
+	Query string
+}
+
+func (r *Resolver) AddSavedSearchWatch(ctx context.Context, args *AddWatchArgs) (*WatchResolver, error) {
+	watch, err := r.Store.AddWatch(ctx, actor.FromContext(ctx).UID, args.Query)
+	if err != nil {
+		return nil, err
+	}
+	return &WatchResolver{watch: watch}, nil
+}
+
+// RemoveWatchArgs are the arguments to the removeSavedSearchWatch
+// mutation. Watch is the watch's internal ID, already unmarshalled from
+// its opaque GraphQL ID by the caller.
+type RemoveWatchArgs struct {
+	Watch int64
+}
+
+func (r *Resolver) RemoveSavedSearchWatch(ctx context.Context, args *RemoveWatchArgs) (*EmptyResponse, error) {
+	if err := r.Store.RemoveWatch(ctx, args.Watch, actor.FromContext(ctx).UID); err != nil {
+		return nil, err
+	}
+	return &EmptyResponse{}, nil
+}
+
+// IgnoreArgs are the arguments shared by the addSavedSearchWatchIgnore
+// and removeSavedSearchWatchIgnore mutations.
+type IgnoreArgs struct {
+	Watch    int64
+	RepoName string
+	ResultID string
+}
+
+func (r *Resolver) AddSavedSearchWatchIgnore(ctx context.Context, args *IgnoreArgs) (*EmptyResponse, error) {
+	userID := actor.FromContext(ctx).UID
+	if err := r.Store.AddIgnore(ctx, args.Watch, userID, args.RepoName, args.ResultID); err != nil {
+		return nil, err
+	}
+	return &EmptyResponse{}, nil
+}
+
+func (r *Resolver) RemoveSavedSearchWatchIgnore(ctx context.Context, args *IgnoreArgs) (*EmptyResponse, error) {
+	userID := actor.FromContext(ctx).UID
+	if err := r.Store.RemoveIgnore(ctx, args.Watch, userID, args.RepoName, args.ResultID); err != nil {
+		return nil, err
+	}
+	return &EmptyResponse{}, nil
+}
+
+// WatchResolver implements the GraphQL SavedSearchWatch type.
+type WatchResolver struct {
+	watch *Watch
+}
+
+func (r *WatchResolver) ID() int64     { return r.watch.ID }
+func (r *WatchResolver) Query() string { return r.watch.Query }
+
+// EmptyResponse implements the common "alwaysNil" GraphQL mutation
+// response used elsewhere for mutations with no meaningful return value.
+type EmptyResponse struct{}
+
+func (EmptyResponse) AlwaysNil() *string { return nil }
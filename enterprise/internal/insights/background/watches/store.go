@@ -0,0 +1,21 @@
+package watches
+
+import "context"
+
+// Store persists Watches, Ignores, and per-watch RunState in the
+// frontend database.
+type Store interface {
+	ListWatches(ctx context.Context) ([]*Watch, error)
+	AddWatch(ctx context.Context, userID int32, query string) (*Watch, error)
+	RemoveWatch(ctx context.Context, watchID int64, userID int32) error
+
+	ListIgnores(ctx context.Context, watchID int64) ([]*Ignore, error)
+	AddIgnore(ctx context.Context, watchID int64, userID int32, repoName, resultID string) error
+	RemoveIgnore(ctx context.Context, watchID int64, userID int32, repoName, resultID string) error
+
+	// GetRunState returns the persisted RunState for watchID, or a
+	// zero-value RunState (with a nil SeenIDs) if the watch has never
+	// run.
+	GetRunState(ctx context.Context, watchID int64) (*RunState, error)
+	SetRunState(ctx context.Context, state *RunState) error
+}
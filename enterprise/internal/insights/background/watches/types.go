@@ -0,0 +1,47 @@
+// Package watches implements a saved-search watcher subsystem: it
+// periodically re-runs user-configured queries via the background search
+// helpers and notifies users only of results that are new since the
+// previous run.
+package watches
+
+import "time"
+
+// Watch is a user-configured saved search that Watcher periodically
+// re-runs.
+type Watch struct {
+	ID        int64
+	UserID    int32
+	Query     string
+	CreatedAt time.Time
+}
+
+// Ignore suppresses notifications for a single result a user has already
+// triaged, scoped to one watch.
+type Ignore struct {
+	WatchID  int64
+	RepoName string
+	ResultID string
+}
+
+// RunState is the persisted state of a single watch: when it last ran
+// and which result IDs it had already seen, so the next run can compute
+// a diff of new matches.
+type RunState struct {
+	WatchID   int64
+	LastRanAt time.Time
+	SeenIDs   map[string]struct{}
+}
+
+// MatchResult identifies a single new search result surfaced to a
+// Notifier.
+type MatchResult struct {
+	RepoName string
+	ResultID string
+}
+
+// NewMatches is dispatched to a Notifier when a watch's run finds
+// results that were not present in the previous run.
+type NewMatches struct {
+	Watch   *Watch
+	Results []MatchResult
+}
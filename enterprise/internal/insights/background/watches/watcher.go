@@ -0,0 +1,167 @@
+package watches
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/sourcegraph/sourcegraph/enterprise/internal/insights/background"
+)
+
+const defaultInterval = 5 * time.Minute
+
+// Watcher periodically re-runs each persisted Watch and notifies only on
+// results that are new since the watch's previous run.
+type Watcher struct {
+	Store    Store
+	Notifier Notifier
+
+	// SearchStream runs a watch's query, paging through results. Defaults
+	// to background.SearchStream; overridable in tests.
+	SearchStream func(ctx context.Context, query string, opts background.SearchStreamOptions) (<-chan background.SearchEvent, error)
+
+	// Interval is how often watches are re-run. Defaults to
+	// defaultInterval if zero.
+	Interval time.Duration
+
+	// Logger receives a one-line diagnostic whenever listing watches, or
+	// a single watch's run, fails. Defaults to a no-op if nil. A failing
+	// watch is logged and skipped rather than propagated, so that one
+	// bad saved search cannot take down notifications for every other
+	// watch.
+	Logger func(format string, args ...interface{})
+}
+
+// Run ticks every w.Interval, re-running all watches, until ctx is
+// cancelled. Per-watch failures are logged and do not stop the ticker.
+func (w *Watcher) Run(ctx context.Context) error {
+	interval := w.Interval
+	if interval == 0 {
+		interval = defaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			w.runOnce(ctx)
+		}
+	}
+}
+
+// runOnce re-runs every persisted watch. Watches run concurrently, both
+// so one slow/stuck watch doesn't delay the others, and so that their
+// underlying search queries - issued around the same time - are good
+// candidates for the gqlclient Batcher to coalesce into a single HTTP
+// round-trip. A single watch's failure is logged and does not affect
+// the others.
+func (w *Watcher) runOnce(ctx context.Context) {
+	watchList, err := w.Store.ListWatches(ctx)
+	if err != nil {
+		w.logf("watches: listing saved-search watches: %v", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, watch := range watchList {
+		wg.Add(1)
+		go func(watch *Watch) {
+			defer wg.Done()
+			if err := w.runWatch(ctx, watch); err != nil {
+				w.logf("watches: watch %d failed, skipping: %v", watch.ID, err)
+			}
+		}(watch)
+	}
+	wg.Wait()
+}
+
+// runWatch re-runs a single watch's query, diffs the results against its
+// previously seen IDs, and notifies the watch's owner of anything new
+// and not ignored.
+func (w *Watcher) runWatch(ctx context.Context, watch *Watch) error {
+	ignored, err := w.ignoreSet(ctx, watch.ID)
+	if err != nil {
+		return err
+	}
+
+	prevState, err := w.Store.GetRunState(ctx, watch.ID)
+	if err != nil {
+		return errors.Wrap(err, "GetRunState")
+	}
+
+	searchStream := w.SearchStream
+	if searchStream == nil {
+		searchStream = background.SearchStream
+	}
+	events, err := searchStream(ctx, watch.Query, background.SearchStreamOptions{})
+	if err != nil {
+		return errors.Wrap(err, "SearchStream")
+	}
+
+	seen := make(map[string]struct{}, len(prevState.SeenIDs))
+	var fresh []MatchResult
+	for ev := range events {
+		if ev.Err != nil && !errors.Is(ev.Err, background.ErrSearchIncomplete) {
+			return errors.Wrap(ev.Err, "SearchStream")
+		}
+
+		for _, m := range ev.Results.Results {
+			id := m.ID()
+			seen[id] = struct{}{}
+
+			if _, wasSeen := prevState.SeenIDs[id]; wasSeen {
+				continue
+			}
+			if _, isIgnored := ignored[id]; isIgnored {
+				continue
+			}
+			fresh = append(fresh, MatchResult{RepoName: m.Repository.Name, ResultID: m.File.Path})
+		}
+
+		if ev.Err != nil {
+			w.logf("watches: watch %d: %v", watch.ID, ev.Err)
+		}
+	}
+
+	if err := w.notify(ctx, watch, fresh); err != nil {
+		return errors.Wrap(err, "Notify")
+	}
+
+	return w.Store.SetRunState(ctx, &RunState{WatchID: watch.ID, LastRanAt: time.Now(), SeenIDs: seen})
+}
+
+// notify delivers fresh to w.Notifier, if there is anything to deliver.
+// It errors instead of panicking when fresh is non-empty but no
+// Notifier is configured.
+func (w *Watcher) notify(ctx context.Context, watch *Watch, fresh []MatchResult) error {
+	if len(fresh) == 0 {
+		return nil
+	}
+	if w.Notifier == nil {
+		return errors.New("watches: Watcher.Notifier is nil, cannot deliver new-match notification")
+	}
+	return w.Notifier.Notify(ctx, watch.UserID, NewMatches{Watch: watch, Results: fresh})
+}
+
+func (w *Watcher) ignoreSet(ctx context.Context, watchID int64) (map[string]struct{}, error) {
+	ignores, err := w.Store.ListIgnores(ctx, watchID)
+	if err != nil {
+		return nil, errors.Wrap(err, "ListIgnores")
+	}
+	set := make(map[string]struct{}, len(ignores))
+	for _, ig := range ignores {
+		set[ig.RepoName+"#"+ig.ResultID] = struct{}{}
+	}
+	return set, nil
+}
+
+func (w *Watcher) logf(format string, args ...interface{}) {
+	if w.Logger != nil {
+		w.Logger(format, args...)
+	}
+}
@@ -0,0 +1,149 @@
+package watches
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/enterprise/internal/insights/background"
+)
+
+type fakeStore struct {
+	mu      sync.Mutex
+	watches []*Watch
+	states  map[int64]*RunState
+}
+
+func newFakeStore(watches ...*Watch) *fakeStore {
+	return &fakeStore{watches: watches, states: map[int64]*RunState{}}
+}
+
+func (s *fakeStore) ListWatches(ctx context.Context) ([]*Watch, error) { return s.watches, nil }
+
+func (s *fakeStore) AddWatch(ctx context.Context, userID int32, query string) (*Watch, error) {
+	return nil, nil
+}
+
+func (s *fakeStore) RemoveWatch(ctx context.Context, watchID int64, userID int32) error { return nil }
+
+func (s *fakeStore) ListIgnores(ctx context.Context, watchID int64) ([]*Ignore, error) {
+	return nil, nil
+}
+
+func (s *fakeStore) AddIgnore(ctx context.Context, watchID int64, userID int32, repoName, resultID string) error {
+	return nil
+}
+
+func (s *fakeStore) RemoveIgnore(ctx context.Context, watchID int64, userID int32, repoName, resultID string) error {
+	return nil
+}
+
+func (s *fakeStore) GetRunState(ctx context.Context, watchID int64) (*RunState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if st, ok := s.states[watchID]; ok {
+		return st, nil
+	}
+	return &RunState{WatchID: watchID}, nil
+}
+
+func (s *fakeStore) SetRunState(ctx context.Context, state *RunState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states[state.WatchID] = state
+	return nil
+}
+
+func (s *fakeStore) ranWatch(id int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.states[id]
+	return ok
+}
+
+// TestWatcher_RunOnce_IsolatesPerWatchFailures asserts that one watch's
+// SearchStream error does not stop runOnce from running the others.
+func TestWatcher_RunOnce_IsolatesPerWatchFailures(t *testing.T) {
+	failing := &Watch{ID: 1, UserID: 1, Query: "boom"}
+	ok := &Watch{ID: 2, UserID: 2, Query: "fine"}
+	store := newFakeStore(failing, ok)
+
+	w := &Watcher{
+		Store: store,
+		SearchStream: func(ctx context.Context, query string, opts background.SearchStreamOptions) (<-chan background.SearchEvent, error) {
+			events := make(chan background.SearchEvent, 1)
+			if query == "boom" {
+				events <- background.SearchEvent{Err: errors.New("boom")}
+			}
+			close(events)
+			return events, nil
+		},
+	}
+
+	w.runOnce(context.Background())
+
+	if store.ranWatch(failing.ID) {
+		t.Error("a failing watch should not persist run state")
+	}
+	if !store.ranWatch(ok.ID) {
+		t.Error("expected the second watch to still run after the first one failed")
+	}
+}
+
+// TestWatcher_Run_SurvivesPerWatchFailure asserts that Run itself does
+// not return/abort when a tick's runOnce encounters a failing watch.
+func TestWatcher_Run_SurvivesPerWatchFailure(t *testing.T) {
+	store := newFakeStore(&Watch{ID: 1, UserID: 1, Query: "boom"})
+	calls := make(chan struct{}, 2)
+	w := &Watcher{
+		Store:    store,
+		Interval: 1,
+		SearchStream: func(ctx context.Context, query string, opts background.SearchStreamOptions) (<-chan background.SearchEvent, error) {
+			select {
+			case calls <- struct{}{}:
+			default:
+			}
+			events := make(chan background.SearchEvent, 1)
+			events <- background.SearchEvent{Err: errors.New("boom")}
+			close(events)
+			return events, nil
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- w.Run(ctx) }()
+
+	// Wait for two ticks, so the second one ran after the first tick's
+	// watch had failed, before cancelling.
+	for i := 0; i < 2; i++ {
+		select {
+		case <-calls:
+		case <-time.After(time.Second):
+			cancel()
+			t.Fatal("timed out waiting for Run to tick again after a failing watch")
+		}
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("expected Run to exit with context.Canceled, got %v", err)
+	}
+}
+
+func TestWatcher_Notify_NilNotifierErrorsInsteadOfPanicking(t *testing.T) {
+	w := &Watcher{}
+	err := w.notify(context.Background(), &Watch{ID: 1}, []MatchResult{{RepoName: "r", ResultID: "f"}})
+	if err == nil {
+		t.Fatal("expected an error for a nil Notifier with fresh matches")
+	}
+}
+
+func TestWatcher_Notify_NoopWhenNoFreshMatches(t *testing.T) {
+	w := &Watcher{} // Notifier intentionally nil
+	if err := w.notify(context.Background(), &Watch{ID: 1}, nil); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package gqlclient
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const defaultBatchWindow = 5 * time.Millisecond
+
+// Future is the result of a query enqueued with Batcher.Query, resolved
+// once the batch it was coalesced into has been sent and its response
+// received.
+type Future struct {
+	done chan struct{}
+	err  error
+}
+
+// Wait blocks until this Future's query has completed. The out value
+// passed to Batcher.Query is populated before Wait returns a nil error.
+func (f *Future) Wait(ctx context.Context) error {
+	select {
+	case <-f.done:
+		return f.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type batchedCall struct {
+	queryName string
+	req       Request
+	out       interface{}
+	future    *Future
+}
+
+// maxBatchNames caps how many of a batch's queryNames are spelled out in
+// the batch's request URL, so a large coalesced batch doesn't produce an
+// unreasonably long URL.
+const maxBatchNames = 8
+
+// batchName joins the queryNames of a flushed batch into a single name
+// for the batch's request URL and logging, eliding names beyond
+// maxBatchNames.
+func batchName(queryNames []string) string {
+	if len(queryNames) <= maxBatchNames {
+		return strings.Join(queryNames, ",")
+	}
+	return strings.Join(queryNames[:maxBatchNames], ",") + ",+more"
+}
+
+// Batcher coalesces concurrent Query calls arriving within Window into a
+// single POST using GraphQL's multi-operation array form
+// ([{query,variables}, ...]), splitting the response array back into
+// per-call Futures with error isolation. Background loops that fan out N
+// searches in quick succession see ~1 HTTP request instead of N.
+//
+// A Batcher is safe for concurrent use. The zero value, aside from
+// Client, is ready to use.
+type Batcher struct {
+	Client *Client
+
+	// Window is how long to wait for more calls to coalesce into the
+	// same batch before sending it. Defaults to defaultBatchWindow if
+	// zero.
+	Window time.Duration
+
+	mu      sync.Mutex
+	pending []*batchedCall
+	timer   *time.Timer
+}
+
+// Query enqueues a query to be sent as part of the next batch and
+// returns a Future that resolves once that batch completes. queryName
+// identifies this call within the batch's request URL and logging, the
+// same as it does for Client.Query. out is populated in place, as with
+// Client.Query.
+//
+// Query does not consult ctx, and ctx does not govern the eventual
+// batched POST: the request this call is coalesced into is shared with other callers,
+// so it is sent with context.Background() rather than any single
+// caller's ctx. Callers that need to bound how long they wait should
+// pass a ctx with a deadline to Future.Wait instead.
+func (b *Batcher) Query(ctx context.Context, queryName string, req Request, out interface{}) *Future {
+	future := &Future{done: make(chan struct{})}
+	call := &batchedCall{queryName: queryName, req: req, out: out, future: future}
+
+	b.mu.Lock()
+	b.pending = append(b.pending, call)
+	if b.timer == nil {
+		window := b.Window
+		if window == 0 {
+			window = defaultBatchWindow
+		}
+		b.timer = time.AfterFunc(window, b.Flush)
+	}
+	b.mu.Unlock()
+
+	return future
+}
+
+// Flush immediately sends any pending queries as a single batched
+// request, instead of waiting for the coalescing window to elapse. It
+// takes no ctx: the resulting POST is shared by every currently-pending
+// caller, so - for the same reason described on Query - it must not be
+// governed by any single one of their contexts, and always runs with
+// context.Background().
+func (b *Batcher) Flush() {
+	b.mu.Lock()
+	calls := b.pending
+	b.pending = nil
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.mu.Unlock()
+
+	if len(calls) == 0 {
+		return
+	}
+
+	reqs := make([]Request, len(calls))
+	names := make([]string, len(calls))
+	for i, call := range calls {
+		reqs[i] = call.req
+		names[i] = call.queryName
+	}
+
+	responses, err := b.Client.batchDo(context.Background(), batchName(names), reqs)
+	for i, call := range calls {
+		switch {
+		case err != nil:
+			call.future.err = err
+		case i >= len(responses):
+			call.future.err = errors.New("gqlclient: batch response missing entry")
+		default:
+			call.future.err = decodeInto(responses[i], call.out)
+		}
+		close(call.future.done)
+	}
+}
+
+func decodeInto(resp response, out interface{}) error {
+	if len(resp.Errors) > 0 {
+		return resp.Errors
+	}
+	if out != nil && len(resp.Data) > 0 {
+		if err := json.Unmarshal(resp.Data, out); err != nil {
+			return errors.Wrap(err, "Unmarshal")
+		}
+	}
+	return nil
+}
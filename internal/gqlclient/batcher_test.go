@@ -0,0 +1,168 @@
+package gqlclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBatcher_CoalescesConcurrentCalls(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		var reqs []Request
+		if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		resps := make([]map[string]interface{}, len(reqs))
+		for i, req := range reqs {
+			vars := req.Variables.(map[string]interface{})
+			resps[i] = map[string]interface{}{"data": map[string]interface{}{"echo": vars["n"]}}
+		}
+		_ = json.NewEncoder(w).Encode(resps)
+	}))
+	defer srv.Close()
+
+	b := &Batcher{Client: New(srv.URL), Window: 10 * time.Millisecond}
+
+	const n = 20
+	var wg sync.WaitGroup
+	results := make([]float64, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var out struct {
+				Echo float64 `json:"echo"`
+			}
+			future := b.Query(context.Background(), "Echo", Request{
+				Query:     "query Echo($n: Int!) { echo(n: $n) }",
+				Variables: map[string]interface{}{"n": i},
+			}, &out)
+			if err := future.Wait(context.Background()); err != nil {
+				t.Errorf("Wait: %v", err)
+				return
+			}
+			results[i] = out.Echo
+		}(i)
+	}
+	wg.Wait()
+
+	for i, got := range results {
+		if int(got) != i {
+			t.Errorf("result %d: got %v", i, got)
+		}
+	}
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("expected calls to coalesce into 1 HTTP request, got %d", got)
+	}
+}
+
+func TestBatcher_IsolatesPerCallErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reqs []Request
+		_ = json.NewDecoder(r.Body).Decode(&reqs)
+
+		resps := make([]map[string]interface{}, len(reqs))
+		for i := range reqs {
+			if i == 0 {
+				resps[i] = map[string]interface{}{"errors": []map[string]interface{}{{"message": "boom"}}}
+				continue
+			}
+			resps[i] = map[string]interface{}{"data": map[string]interface{}{"ok": true}}
+		}
+		_ = json.NewEncoder(w).Encode(resps)
+	}))
+	defer srv.Close()
+
+	b := &Batcher{Client: New(srv.URL), Window: 10 * time.Millisecond}
+
+	var out1, out2 struct {
+		OK bool `json:"ok"`
+	}
+	f1 := b.Query(context.Background(), "A", Request{Query: "query A {}"}, &out1)
+	f2 := b.Query(context.Background(), "B", Request{Query: "query B {}"}, &out2)
+
+	if err := f1.Wait(context.Background()); err == nil {
+		t.Fatal("expected an error for the first call")
+	}
+	if err := f2.Wait(context.Background()); err != nil {
+		t.Fatalf("expected the second call to succeed, got %v", err)
+	}
+	if !out2.OK {
+		t.Fatal("expected out2.OK = true")
+	}
+}
+
+// BenchmarkUnbatched issues b.N queries one at a time directly against
+// Client.Query, each a full HTTP round-trip.
+func BenchmarkUnbatched(b *testing.B) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"ok": true}})
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var out struct {
+			OK bool `json:"ok"`
+		}
+		if err := c.Query(context.Background(), "Bench", Request{Query: "query {}"}, &out); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkBatched fans the same workload out concurrently through a
+// Batcher, demonstrating the reduction in HTTP round-trips on a
+// realistic "many background jobs search at once" workload: all b.N
+// queries are started at once, and the reported http_requests metric
+// (the total number of batched POSTs) is far below the b.N round-trips
+// BenchmarkUnbatched makes.
+func BenchmarkBatched(b *testing.B) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		var reqs []Request
+		_ = json.NewDecoder(r.Body).Decode(&reqs)
+
+		resps := make([]map[string]interface{}, len(reqs))
+		for i := range reqs {
+			resps[i] = map[string]interface{}{"data": map[string]interface{}{"ok": true}}
+		}
+		_ = json.NewEncoder(w).Encode(resps)
+	}))
+	defer srv.Close()
+
+	batcher := &Batcher{Client: New(srv.URL), Window: 5 * time.Millisecond}
+
+	b.ResetTimer()
+	var wg sync.WaitGroup
+	for i := 0; i < b.N; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var out struct {
+				OK bool `json:"ok"`
+			}
+			future := batcher.Query(context.Background(), "Bench", Request{Query: "query {}"}, &out)
+			if err := future.Wait(context.Background()); err != nil {
+				b.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	b.StopTimer()
+
+	b.ReportMetric(float64(atomic.LoadInt32(&requests)), "http_requests")
+}
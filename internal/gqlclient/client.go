@@ -0,0 +1,264 @@
+// Package gqlclient provides a client for calling Sourcegraph's internal
+// GraphQL API (/.internal/graphql) from background jobs and other
+// non-HTTP-handler code. Unlike a one-shot http.Post, it authenticates
+// requests, retries requests (mutations included) on transient failures, and
+// surfaces GraphQL-level errors as typed values that callers can
+// errors.As against.
+package gqlclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	mathrand "math/rand"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/sourcegraph/sourcegraph/internal/env"
+)
+
+// defaultAuthSecret is read once from the INTERNAL_GRAPHQL_SHARED_SECRET
+// environment variable; it is not read from site configuration. Client.Secret
+// defaults to it, but callers (and tests) can set Secret directly on a
+// Client to sign with a different value, or unset it to exercise the
+// unsigned fallback, without touching the environment.
+var defaultAuthSecret = env.Get("INTERNAL_GRAPHQL_SHARED_SECRET", "", "shared secret used to sign requests to the internal GraphQL API")
+
+// internalClientName identifies this process to the frontend's internal
+// GraphQL endpoint, mirroring the "Internal" auth scheme used for other
+// service-to-service calls.
+const internalClientName = "sourcegraph-internal"
+
+// processClientID is a per-process identifier included alongside the
+// signature so the frontend can distinguish callers in logs.
+var processClientID = randomHex(8)
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+const (
+	defaultMaxRetries = 3
+	baseBackoff       = 100 * time.Millisecond
+)
+
+var defaultHTTPClient = &http.Client{
+	Timeout: 30 * time.Second,
+	Transport: &http.Transport{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     90 * time.Second,
+	},
+}
+
+// Client calls the frontend's internal GraphQL API (/.internal/graphql).
+type Client struct {
+	// URL is the base URL of the frontend, e.g. api.InternalClient.URL.
+	URL string
+
+	// HTTPClient is used to make requests. If nil, a client with sane
+	// timeouts and connection pooling is used.
+	HTTPClient *http.Client
+
+	// ClientID is included in the signed internal auth header. If empty,
+	// a per-process random ID is used.
+	ClientID string
+
+	// MaxRetries is the maximum number of retry attempts on 5xx responses
+	// or network errors. Every request is retried, mutations included;
+	// idempotent and non-idempotent operations are not told apart. Zero
+	// uses defaultMaxRetries.
+	MaxRetries int
+
+	// Secret signs the internal auth header (see sign). Defaults to the
+	// INTERNAL_GRAPHQL_SHARED_SECRET environment variable. If empty,
+	// requests are sent unsigned.
+	Secret string
+}
+
+// New returns a Client that talks to the frontend's internal API at url.
+func New(url string) *Client {
+	return &Client{URL: url, Secret: defaultAuthSecret}
+}
+
+// Request is a single GraphQL operation.
+type Request struct {
+	Query     string      `json:"query"`
+	Variables interface{} `json:"variables"`
+}
+
+type response struct {
+	Data   json.RawMessage `json:"data"`
+	Errors GQLErrors       `json:"errors"`
+}
+
+// Query executes a single GraphQL query or mutation and decodes its
+// "data" field into out. queryName is used for the request URL and
+// request logging, as before. The request is retried with exponential
+// backoff and jitter on 5xx responses and network errors.
+func (c *Client) Query(ctx context.Context, queryName string, req Request, out interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return errors.Wrap(err, "Marshal")
+	}
+
+	u, err := c.url(queryName)
+	if err != nil {
+		return errors.Wrap(err, "constructing frontend URL")
+	}
+
+	var resp response
+	if err := c.withRetry(ctx, func() error {
+		respBody, doErr := c.post(ctx, u, body)
+		if doErr != nil {
+			return doErr
+		}
+		if err := json.Unmarshal(respBody, &resp); err != nil {
+			return errors.Wrapf(err, "decoding response body: %s", bytes.TrimSpace(respBody))
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return decodeInto(resp, out)
+}
+
+// post sends body as a POST to u, with authentication, and returns the
+// raw response body. It classifies network errors and 5xx responses as
+// retryable.
+func (c *Client) post(ctx context.Context, u string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "NewRequest")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.sign(req, body)
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = defaultHTTPClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, retryableError{err}
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading response body")
+	}
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("internal graphql: POST %s: unexpected status %d: %s", u, resp.StatusCode, bytes.TrimSpace(respBody))
+		if resp.StatusCode >= 500 {
+			return nil, retryableError{err}
+		}
+		return nil, err
+	}
+	return respBody, nil
+}
+
+// sign attaches a service-identity header to req, signing body with
+// c.Secret. If Secret is empty, the request is sent unsigned and
+// authentication falls back to network-level trust, as before.
+func (c *Client) sign(req *http.Request, body []byte) {
+	if c.Secret == "" {
+		return
+	}
+	clientID := c.ClientID
+	if clientID == "" {
+		clientID = processClientID
+	}
+	mac := hmac.New(sha256.New, []byte(c.Secret))
+	mac.Write(body)
+	sig := hex.EncodeToString(mac.Sum(nil))
+	req.Header.Set("Authorization", fmt.Sprintf("Internal %s:%s:%s", internalClientName, clientID, sig))
+}
+
+// batchDo POSTs reqs as a single GraphQL multi-operation array
+// ([{query,variables}, ...]) and returns one response per request, in
+// the same order. batchName identifies the batch in the request URL and
+// logging, the same way queryName does for Query. Used by Batcher to
+// amortize round-trips across concurrent callers.
+func (c *Client) batchDo(ctx context.Context, batchName string, reqs []Request) ([]response, error) {
+	body, err := json.Marshal(reqs)
+	if err != nil {
+		return nil, errors.Wrap(err, "Marshal")
+	}
+
+	u, err := c.url(batchName)
+	if err != nil {
+		return nil, errors.Wrap(err, "constructing frontend URL")
+	}
+
+	var responses []response
+	err = c.withRetry(ctx, func() error {
+		respBody, doErr := c.post(ctx, u, body)
+		if doErr != nil {
+			return doErr
+		}
+		if err := json.Unmarshal(respBody, &responses); err != nil {
+			return errors.Wrapf(err, "decoding batch response body: %s", bytes.TrimSpace(respBody))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return responses, nil
+}
+
+func (c *Client) url(queryName string) (string, error) {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return "", err
+	}
+	u.Path = "/.internal/graphql"
+	u.RawQuery = queryName
+	return u.String(), nil
+}
+
+// retryableError wraps an error that is safe to retry (a network error or
+// a 5xx response).
+type retryableError struct{ err error }
+
+func (e retryableError) Error() string { return e.err.Error() }
+func (e retryableError) Unwrap() error { return e.err }
+
+func (c *Client) withRetry(ctx context.Context, fn func() error) error {
+	maxRetries := c.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = defaultMaxRetries
+	}
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = fn()
+		var re retryableError
+		if err == nil || !errors.As(err, &re) || attempt == maxRetries {
+			break
+		}
+
+		backoff := time.Duration(float64(baseBackoff) * math.Pow(2, float64(attempt)))
+		jitter := time.Duration(mathrand.Int63n(int64(backoff)))
+		select {
+		case <-time.After(backoff + jitter):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return err
+}
@@ -0,0 +1,134 @@
+package gqlclient
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestClient_Query_RetriesOn5xxThenSucceeds(t *testing.T) {
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"ok": true}})
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL)
+	c.MaxRetries = 5
+
+	var out struct {
+		OK bool `json:"ok"`
+	}
+	if err := c.Query(context.Background(), "Test", Request{Query: "query {}"}, &out); err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if !out.OK {
+		t.Fatal("expected ok = true")
+	}
+	if got := atomic.LoadInt32(&attempts); got != 3 {
+		t.Fatalf("expected 3 attempts, got %d", got)
+	}
+}
+
+func TestClient_Query_GivesUpAfterMaxRetries(t *testing.T) {
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL)
+	c.MaxRetries = 2
+
+	if err := c.Query(context.Background(), "Test", Request{Query: "query {}"}, nil); err == nil {
+		t.Fatal("expected an error")
+	}
+	if got := atomic.LoadInt32(&attempts); got != 3 {
+		t.Fatalf("expected 3 attempts (1 + 2 retries), got %d", got)
+	}
+}
+
+func TestClient_Query_DoesNotRetryOn4xx(t *testing.T) {
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL)
+	c.MaxRetries = 5
+
+	if err := c.Query(context.Background(), "Test", Request{Query: "query {}"}, nil); err == nil {
+		t.Fatal("expected an error")
+	}
+	if got := atomic.LoadInt32(&attempts); got != 1 {
+		t.Fatalf("expected 1 attempt, got %d", got)
+	}
+}
+
+func TestClient_sign_SetsWellFormedAuthHeader(t *testing.T) {
+	c := New("http://example.com")
+	c.Secret = "s3cr3t"
+	c.ClientID = "test-client"
+
+	body := []byte(`{"query":"{}"}`)
+	req, err := http.NewRequest(http.MethodPost, "http://example.com", nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	c.sign(req, body)
+
+	const prefix = "Internal sourcegraph-internal:test-client:"
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, prefix) {
+		t.Fatalf("Authorization = %q, want prefix %q", auth, prefix)
+	}
+
+	mac := hmac.New(sha256.New, []byte(c.Secret))
+	mac.Write(body)
+	wantSig := hex.EncodeToString(mac.Sum(nil))
+	if gotSig := strings.TrimPrefix(auth, prefix); gotSig != wantSig {
+		t.Fatalf("signature = %q, want %q", gotSig, wantSig)
+	}
+}
+
+func TestClient_sign_NoSecretLeavesRequestUnsigned(t *testing.T) {
+	c := New("http://example.com")
+	c.Secret = ""
+
+	req, err := http.NewRequest(http.MethodPost, "http://example.com", nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	c.sign(req, []byte("body"))
+
+	if got := req.Header.Get("Authorization"); got != "" {
+		t.Fatalf("expected no Authorization header, got %q", got)
+	}
+}
+
+func TestGQLErrors_As(t *testing.T) {
+	errs := GQLErrors{{Message: "not found", Extensions: map[string]interface{}{"code": "NOT_FOUND"}}}
+
+	var gqlErr *GQLError
+	if !errors.As(error(errs), &gqlErr) {
+		t.Fatal("expected errors.As to succeed")
+	}
+	if gqlErr.Code() != "NOT_FOUND" {
+		t.Fatalf("got code %q, want NOT_FOUND", gqlErr.Code())
+	}
+}
@@ -0,0 +1,53 @@
+package gqlclient
+
+import "strings"
+
+// GQLError is a single entry from a GraphQL response's "errors" array.
+type GQLError struct {
+	Message    string                 `json:"message"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+func (e *GQLError) Error() string { return e.Message }
+
+// Code returns the "code" extension (e.g. "NOT_FOUND"), if set.
+func (e *GQLError) Code() string {
+	if e == nil || e.Extensions == nil {
+		return ""
+	}
+	code, _ := e.Extensions["code"].(string)
+	return code
+}
+
+// GQLErrors is the "errors" array of a GraphQL response. It implements
+// error so callers that don't care about individual errors can treat a
+// query result as a plain error, while callers that do can
+// errors.As(err, &gqlErr) to inspect the first error's Code.
+type GQLErrors []*GQLError
+
+func (e GQLErrors) Error() string {
+	switch len(e) {
+	case 0:
+		return "graphql: no errors"
+	case 1:
+		return e[0].Message
+	default:
+		msgs := make([]string, len(e))
+		for i, err := range e {
+			msgs[i] = err.Message
+		}
+		return "graphql: " + strings.Join(msgs, "; ")
+	}
+}
+
+// As implements the interface used by errors.As, allowing callers to
+// extract the first *GQLError (e.g. to check its Code).
+func (e GQLErrors) As(target interface{}) bool {
+	t, ok := target.(**GQLError)
+	if !ok || len(e) == 0 {
+		return false
+	}
+	*t = e[0]
+	return true
+}